@@ -837,10 +837,37 @@ def write_compressed_data(data: bytes, d: BinaryIO) -> None:
     d.write(bz2.compress(data))
 
 
+def unicode_names_trigram_index(words: set[bytes]) -> bytes:
+    # The sorted words, followed by a table, sorted by trigram, of every three
+    # byte sequence with the sorted indices of the words that contain it
+    all_words = sorted(words)
+    if len(all_words) > 0xffff:
+        raise Exception('Too many words in unicode names')
+    trigrams: dict[bytes, list[int]] = {}
+    for i, w in enumerate(all_words):
+        for j in range(len(w) - 2):
+            p = trigrams.setdefault(w[j:j+3], [])
+            if not p or p[-1] != i:
+                p.append(i)
+    gob = io.BytesIO()
+    gob.write(struct.pack('<I', len(all_words)))
+    for w in all_words:
+        gob.write(struct.pack('<B', len(w)) + w)
+    keys = sorted(trigrams)
+    gob.write(struct.pack('<I', len(keys)))
+    offset = 0
+    for k in keys:
+        gob.write(k + struct.pack('<IH', offset, len(trigrams[k])))
+        offset += len(trigrams[k])
+    for k in keys:
+        gob.write(struct.pack(f'<{len(trigrams[k])}H', *trigrams[k]))
+    return gob.getvalue()
+
+
 def generate_unicode_names(src: TextIO, dest: BinaryIO) -> None:
     num_names, num_of_words = map(int, next(src).split())
-    gob = io.BytesIO()
-    gob.write(struct.pack('<II', num_names, num_of_words))
+    records = io.BytesIO()
+    words: set[bytes] = set()
     for line in src:
         line = line.strip()
         if line:
@@ -850,7 +877,12 @@ def generate_unicode_names(src: TextIO, dest: BinaryIO) -> None:
             record = struct.pack('<IH', int(cp), len(ename)) + ename
             if aliases:
                 record += aliases.encode()
-            gob.write(struct.pack('<H', len(record)) + record)
+            words.update(w for w in (name + ' ' + aliases).encode().split() if len(w) > 1)
+            records.write(struct.pack('<H', len(record)) + record)
+    gob = io.BytesIO()
+    gob.write(struct.pack('<III', num_names, num_of_words, len(records.getvalue())))
+    gob.write(records.getvalue())
+    gob.write(unicode_names_trigram_index(words))
     write_compressed_data(gob.getvalue(), dest)
 
 
@@ -87,7 +87,7 @@ func serialize_favorites(favs []rune) string {
 
 `)
 	for _, ch := range favs {
-		b.WriteString(fmt.Sprintf("%x # %s %s\n", ch, string(ch), unicode_names.NameForCodePoint(ch)))
+		b.WriteString(fmt.Sprintf("%x # %s %s\n", ch, string(ch), unicode_names.NameFor(ch)))
 	}
 
 	return b.String()
@@ -241,7 +241,7 @@ func (self *handler) update_codepoints() {
 			query := strings.Join(words, " ")
 			if len(query) > 1 {
 				words = words[1:]
-				q.codepoints = unicode_names.CodePointsForQuery(query)
+				q.codepoints = unicode_names.Search(query)
 			}
 		}
 	}
@@ -293,7 +293,7 @@ func (self *handler) update_prompt() {
 		ch, color = self.resolved_char(), "green"
 		self.choice_line = fmt.Sprintf(
 			"Chosen: %s U+%x %s", self.chosen_formatter(ch), self.current_char,
-			self.chosen_name_formatter(title(unicode_names.NameForCodePoint(self.current_char))))
+			self.chosen_name_formatter(title(unicode_names.NameFor(self.current_char))))
 	}
 	prompt := fmt.Sprintf("%s> ", self.ctx.SprintFunc("fg="+color)(ch))
 	self.rl.SetPrompt(prompt)
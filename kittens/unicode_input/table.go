@@ -88,7 +88,8 @@ func (self *table) current_codepoint() rune {
 func (self *table) set_codepoints(codepoints []rune, mode Mode, current_idx int) {
 	delta := len(codepoints) - len(self.codepoints)
 	self.codepoints = codepoints
-	if self.codepoints != nil && mode != FAVORITES && mode != HEX {
+	// name search results are already sorted best match first
+	if self.codepoints != nil && mode != FAVORITES && mode != HEX && mode != NAME {
 		slices.Sort(self.codepoints)
 	}
 	self.mode = mode
@@ -137,7 +138,7 @@ func (self *table) layout(rows, cols int) string {
 	switch self.mode {
 	case NAME:
 		as_parts = func(i int, codepoint rune) cell_data {
-			return cell_data{idx: ljust(encode_hint(i), idx_size), ch: resolved_char(codepoint, self.emoji_variation), desc: title(unicode_names.NameForCodePoint(codepoint))}
+			return cell_data{idx: ljust(encode_hint(i), idx_size), ch: resolved_char(codepoint, self.emoji_variation), desc: title(unicode_names.NameFor(codepoint))}
 		}
 
 		cell = func(i int, cd cell_data) {
@@ -0,0 +1,162 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"kitty/tools/cli"
+	"kitty/tools/config"
+	"kitty/tools/tui/loop"
+)
+
+var _ = fmt.Print
+
+// KittenApp implements the boilerplate common to all loop based kittens:
+// loading of config files, keyboard shortcuts, running the loop and computing
+// the exit code. Use its Main method as the function passed to the
+// create_cmd() of the kitten so that command line parsing is done as usual. A
+// typical kitten then only needs to provide the Setup and OnAction callbacks.
+// Panics in the loop callbacks are reported by loop.Run(), panics in Setup
+// and AfterRun are not recovered.
+type KittenApp[Options any] struct {
+	// The name of the config file for this kitten, for example, diff.conf. If
+	// empty, no config file is loaded.
+	ConfigName string
+	// Return the config file paths and overrides specified on the command line
+	ConfigPaths func(opts *Options) (paths, overrides []string)
+	// Called for every line in the config files, except for map lines, which
+	// are handled automatically. Returning an error marks the line as bad, bad
+	// lines are reported on stderr and otherwise ignored.
+	ParseConfigLine func(key, val string) error
+
+	// Keyboard shortcuts used when not overridden by the config file, in the
+	// same format as the value of a map directive, for example: "ctrl+c quit"
+	DefaultShortcuts []string
+	// Called when a keyboard shortcut is triggered
+	OnAction func(name, args string) error
+	// Called for key events that do not match any keyboard shortcut. If Setup
+	// sets Loop.OnKeyEvent instead, that is used.
+	OnKeyEvent func(ev *loop.KeyEvent) error
+
+	// Options used to create the loop, for example, loop.NoAlternateScreen
+	LoopOptions []func(*loop.Loop)
+	// Called after options, config and shortcuts have been loaded and the loop
+	// created but before it is run. Use it to validate args and set the loop
+	// callbacks. Returning an error aborts the kitten with exit code 1.
+	Setup func(args []string) error
+	// Called after the loop has exited normally with its exit code. Use it to
+	// output results. The returned values are used as the exit code and error
+	// for the kitten.
+	AfterRun func(exit_code int) (int, error)
+
+	// Available once Main has been called
+	Opts *Options
+	// Available once Main has been called
+	Loop *loop.Loop
+	// The resolved keyboard shortcuts, available once Main has been called
+	KeyboardShortcuts []*config.KeyAction
+	// The lines from the config files and overrides that could not be
+	// parsed, available once Main has been called
+	BadConfigLines []config.ConfigLine
+
+	shortcut_tracker config.ShortcutTracker
+}
+
+func (self *KittenApp[Options]) load_config() (err error) {
+	shortcuts := make([]*config.KeyAction, 0, len(self.DefaultShortcuts))
+	for _, spec := range self.DefaultShortcuts {
+		ac, err := config.ParseMap(spec)
+		if err != nil {
+			return fmt.Errorf("Invalid default shortcut: %s with error: %w", spec, err)
+		}
+		shortcuts = append(shortcuts, ac)
+	}
+	if self.ConfigName != "" {
+		var paths, overrides []string
+		if self.ConfigPaths != nil {
+			paths, overrides = self.ConfigPaths(self.Opts)
+		}
+		handle_line := func(key, val string) error {
+			if key == "map" {
+				ac, err := config.ParseMap(val)
+				if err != nil {
+					return err
+				}
+				shortcuts = append(shortcuts, ac)
+				return nil
+			}
+			if self.ParseConfigLine != nil {
+				return self.ParseConfigLine(key, val)
+			}
+			return nil
+		}
+		p := config.ConfigParser{LineHandler: handle_line}
+		if err = p.LoadConfig(self.ConfigName, paths, overrides); err != nil {
+			return err
+		}
+		self.BadConfigLines = p.BadLines()
+	}
+	self.KeyboardShortcuts = config.ResolveShortcuts(shortcuts)
+	return nil
+}
+
+func (self *KittenApp[Options]) on_key_event(ev *loop.KeyEvent) error {
+	if ac := self.shortcut_tracker.Match(ev, self.KeyboardShortcuts); ac != nil {
+		ev.Handled = true
+		if self.OnAction != nil {
+			return self.OnAction(ac.Name, ac.Args)
+		}
+		return nil
+	}
+	if ev.Handled || self.OnKeyEvent == nil {
+		return nil
+	}
+	return self.OnKeyEvent(ev)
+}
+
+func (self *KittenApp[Options]) setup(opts *Options, args []string) (err error) {
+	self.Opts = opts
+	if err = self.load_config(); err != nil {
+		return err
+	}
+	for _, x := range self.BadConfigLines {
+		fmt.Fprintf(os.Stderr, "Ignoring bad config line: %s:%d with error: %s\n", filepath.Base(x.Src_file), x.Line_number, x.Err)
+	}
+	if self.Loop, err = loop.New(self.LoopOptions...); err != nil {
+		return err
+	}
+	if self.Setup != nil {
+		if err = self.Setup(args); err != nil {
+			return err
+		}
+	}
+	// set after Setup so that shortcuts work even if Setup sets Loop.OnKeyEvent
+	if self.Loop.OnKeyEvent != nil && self.OnKeyEvent == nil {
+		self.OnKeyEvent = self.Loop.OnKeyEvent
+	}
+	self.Loop.OnKeyEvent = self.on_key_event
+	return nil
+}
+
+// Run the kitten, suitable for passing to create_cmd()
+func (self *KittenApp[Options]) Main(_ *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if err = self.setup(opts, args); err != nil {
+		return 1, err
+	}
+	if err = self.Loop.Run(); err != nil {
+		return 1, err
+	}
+	if ds := self.Loop.DeathSignalName(); ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		self.Loop.KillIfSignalled()
+		return 1, nil
+	}
+	rc = self.Loop.ExitCode()
+	if self.AfterRun != nil {
+		return self.AfterRun(rc)
+	}
+	return rc, nil
+}
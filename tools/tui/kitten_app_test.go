@@ -0,0 +1,127 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"kitty/tools/tui/loop"
+)
+
+var _ = fmt.Print
+
+func TestKittenAppLoadConfig(t *testing.T) {
+	tdir := t.TempDir()
+	conf_file := filepath.Join(tdir, "test.conf")
+	type options struct{}
+	var parsed_lines []string
+	app := KittenApp[options]{
+		ConfigName:       "test.conf",
+		ConfigPaths:      func(*options) ([]string, []string) { return []string{conf_file}, nil },
+		DefaultShortcuts: []string{"ctrl+c quit", "q quit", "j scroll_by 1"},
+		ParseConfigLine: func(key, val string) error {
+			if key == "bad" {
+				return fmt.Errorf("bad value: %s", val)
+			}
+			parsed_lines = append(parsed_lines, key+" "+val)
+			return nil
+		},
+	}
+	load := func(conf string) error {
+		t.Helper()
+		if err := os.WriteFile(conf_file, []byte(conf), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		parsed_lines = nil
+		return app.load_config()
+	}
+	shortcuts := func() map[string]string {
+		ans := make(map[string]string, len(app.KeyboardShortcuts))
+		for _, ac := range app.KeyboardShortcuts {
+			ans[ac.Normalized_keys[0]] = strings.TrimSpace(ac.Name + " " + ac.Args)
+		}
+		return ans
+	}
+	check := func(expected map[string]string) {
+		t.Helper()
+		if diff := cmp.Diff(expected, shortcuts()); diff != "" {
+			t.Fatalf("Unexpected shortcuts:\n%s", diff)
+		}
+	}
+
+	if err := load(""); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]string{"ctrl+c": "quit", "q": "quit", "j": "scroll_by 1"})
+
+	if err := load("map ctrl+c copy_to_clipboard\nmap q no_op\nsetting x\n"); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]string{"ctrl+c": "copy_to_clipboard", "j": "scroll_by 1"})
+	if diff := cmp.Diff([]string{"setting x"}, parsed_lines); diff != "" {
+		t.Fatalf("Unexpected parsed lines:\n%s", diff)
+	}
+
+	// bad lines are recorded and otherwise ignored
+	if err := load("setting x\nbad value\nmap ctrl+x\nmap j scroll_by 2\n"); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]string{"ctrl+c": "quit", "q": "quit", "j": "scroll_by 2"})
+	if diff := cmp.Diff([]string{"setting x"}, parsed_lines); diff != "" {
+		t.Fatalf("Unexpected parsed lines:\n%s", diff)
+	}
+	bad := make([]string, len(app.BadConfigLines))
+	for i, bl := range app.BadConfigLines {
+		bad[i] = fmt.Sprintf("%d: %s", bl.Line_number, bl.Line)
+		if bl.Err == nil || bl.Src_file != conf_file {
+			t.Fatalf("Bad line has incorrect metadata: %#v", bl)
+		}
+	}
+	if diff := cmp.Diff([]string{"2: bad value", "3: map ctrl+x"}, bad); diff != "" {
+		t.Fatalf("Unexpected bad lines:\n%s", diff)
+	}
+}
+
+func TestKittenAppKeyEvents(t *testing.T) {
+	type options struct{}
+	var actions, key_events []string
+	app := KittenApp[options]{
+		DefaultShortcuts: []string{"ctrl+c quit", "q quit"},
+		OnAction:         func(name, args string) error { actions = append(actions, name); return nil },
+	}
+	// Setup sets Loop.OnKeyEvent rather than app.OnKeyEvent
+	app.Setup = func(args []string) error {
+		app.Loop.OnKeyEvent = func(ev *loop.KeyEvent) error { key_events = append(key_events, ev.Key); return nil }
+		return nil
+	}
+	if err := app.setup(&options{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	send := func(key string, mods loop.KeyModifiers) *loop.KeyEvent {
+		t.Helper()
+		ev := &loop.KeyEvent{Type: loop.PRESS, Key: key, Mods: mods}
+		if err := app.Loop.OnKeyEvent(ev); err != nil {
+			t.Fatal(err)
+		}
+		return ev
+	}
+	if ev := send("c", loop.CTRL); !ev.Handled {
+		t.Fatalf("Shortcut key event not marked as handled")
+	}
+	if ev := send("x", 0); ev.Handled {
+		t.Fatalf("Non shortcut key event marked as handled")
+	}
+	send("q", 0)
+	if diff := cmp.Diff([]string{"quit", "quit"}, actions); diff != "" {
+		t.Fatalf("Unexpected actions:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"x"}, key_events); diff != "" {
+		t.Fatalf("Unexpected key events passed through:\n%s", diff)
+	}
+}
@@ -57,6 +57,12 @@ type Loop struct {
 	style_ctx                              style.Context
 	atomic_update_active                   bool
 	pointer_shapes                         []PointerShape
+	detect_styled_underlines               bool
+	no_styled_underlines                   bool
+	subprocesses                           map[IdType]*Subprocess
+	subprocess_id_counter                  IdType
+	subprocess_channel                     chan subprocess_event
+	subprocess_done_channel                chan byte
 
 	// Suspend the loop restoring terminal state, and run the provided function. When it returns terminal state is
 	// put back to what it was before suspending unless the function returns an error or an error occurs saving/restoring state.
@@ -111,6 +117,10 @@ type Loop struct {
 
 	// Called on SIGTERM return true if you wish to handle it yourself
 	OnSIGTERM func() (bool, error)
+
+	// Called when the terminal reports support for styled underlines, see
+	// DetectStyledUnderlines. Redraw the screen here to use them.
+	OnStyledUnderlinesSupported func() error
 }
 
 func New(options ...func(self *Loop)) (*Loop, error) {
@@ -196,6 +206,36 @@ func NoRestoreColors(self *Loop) {
 	self.terminal_options.restore_colors = false
 }
 
+// Render styled underlines as straight underlines and ignore underline colors,
+// for terminals that do not support them. Takes precedence over
+// DetectStyledUnderlines, regardless of the order in which they are used.
+func (self *Loop) NoStyledUnderlines() *Loop {
+	self.no_styled_underlines = true
+	self.detect_styled_underlines = false
+	self.style_ctx.NoStyledUnderlines = true
+	clear(self.style_cache)
+	return self
+}
+
+func NoStyledUnderlines(self *Loop) {
+	self.NoStyledUnderlines()
+}
+
+// Query the terminal for support for styled underlines (the Smulx terminfo
+// capability) on startup. Until the terminal reports support, styled
+// underlines are rendered as straight underlines and underline colors are
+// ignored, so terminals that do not answer the query get the fallback.
+// OnStyledUnderlinesSupported is called if support is reported. Has no
+// effect if NoStyledUnderlines is used.
+func (self *Loop) DetectStyledUnderlines() *Loop {
+	self.detect_styled_underlines = !self.no_styled_underlines
+	return self
+}
+
+func DetectStyledUnderlines(self *Loop) {
+	self.DetectStyledUnderlines()
+}
+
 func NoInBandResizeNotifications(self *Loop) {
 	self.terminal_options.in_band_resize_notification = false
 }
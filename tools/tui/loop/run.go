@@ -198,7 +198,31 @@ func (self *Loop) handle_osc(raw []byte) error {
 	return nil
 }
 
+var styled_underlines_query = "\x1bP+q" + hex.EncodeToString([]byte("Smulx")) + "\x1b\\"
+
+// Handle the response to styled_underlines_query, returns true if raw is such a response
+func (self *Loop) handle_styled_underlines_response(raw []byte) (bool, error) {
+	if !self.detect_styled_underlines || !(bytes.HasPrefix(raw, []byte("1+r")) || bytes.HasPrefix(raw, []byte("0+r"))) {
+		return false, nil
+	}
+	key, _, _ := strings.Cut(utils.UnsafeBytesToString(raw[3:]), "=")
+	if k, err := hex.DecodeString(key); err != nil || string(k) != "Smulx" {
+		return false, nil
+	}
+	if raw[0] == '1' && self.style_ctx.NoStyledUnderlines {
+		self.style_ctx.NoStyledUnderlines = false
+		clear(self.style_cache)
+		if self.OnStyledUnderlinesSupported != nil {
+			return true, self.OnStyledUnderlinesSupported()
+		}
+	}
+	return true, nil
+}
+
 func (self *Loop) handle_dcs(raw []byte) error {
+	if handled, err := self.handle_styled_underlines_response(raw); handled {
+		return err
+	}
 	if self.OnRCResponse != nil && bytes.HasPrefix(raw, utils.UnsafeStringToBytes("@kitty-cmd")) {
 		return self.OnRCResponse(raw[len("@kitty-cmd"):])
 	}
@@ -285,6 +309,8 @@ func (self *Loop) on_signal(s unix.Signal) error {
 		return self.on_SIGTSTP()
 	case unix.SIGHUP:
 		return self.on_SIGHUP()
+	case unix.SIGCHLD:
+		return self.on_SIGCHLD()
 	default:
 		return nil
 	}
@@ -331,7 +357,7 @@ func (self *Loop) on_SIGHUP() error {
 
 func (self *Loop) run() (err error) {
 	signal_channel := make(chan os.Signal, 256)
-	handled_signals := []os.Signal{unix.SIGINT, unix.SIGTERM, unix.SIGTSTP, unix.SIGHUP, unix.SIGWINCH, unix.SIGPIPE}
+	handled_signals := []os.Signal{unix.SIGINT, unix.SIGTERM, unix.SIGTSTP, unix.SIGHUP, unix.SIGWINCH, unix.SIGPIPE, unix.SIGCHLD}
 	signal.Notify(signal_channel, handled_signals...)
 	defer signal.Reset(handled_signals...)
 
@@ -362,6 +388,9 @@ func (self *Loop) run() (err error) {
 	self.exit_code = 0
 	self.atomic_update_active = false
 	self.timers, self.timers_temp = make([]*timer, 0, 8), make([]*timer, 0, 8)
+	self.subprocesses = make(map[IdType]*Subprocess)
+	self.subprocess_channel = make(chan subprocess_event, 256)
+	self.subprocess_done_channel = make(chan byte)
 	no_timeout_channel := make(<-chan time.Time)
 	finalizer := ""
 
@@ -390,6 +419,11 @@ func (self *Loop) run() (err error) {
 
 	self.QueueWriteString(self.terminal_options.SetStateEscapeCodes())
 	needs_reset_escape_codes := true
+	if self.detect_styled_underlines {
+		self.style_ctx.NoStyledUnderlines = true
+		clear(self.style_cache)
+		self.QueueWriteString(styled_underlines_query)
+	}
 
 	shutdown_tty_reader := func() {
 		// notify tty reader that we are shutting down
@@ -408,6 +442,8 @@ func (self *Loop) run() (err error) {
 
 	defer func() {
 		shutdown_tty_reader()
+		close(self.subprocess_done_channel)
+		self.kill_subprocesses()
 
 		if self.OnFinalize != nil {
 			finalizer += self.OnFinalize()
@@ -532,6 +568,11 @@ func (self *Loop) run() (err error) {
 			}
 		case rwerr := <-err_channel:
 			return fmt.Errorf("Failed doing I/O with terminal: %w", rwerr)
+		case ev := <-self.subprocess_channel:
+			err = self.dispatch_subprocess_event(ev)
+			if err != nil {
+				return err
+			}
 		case s := <-signal_channel:
 			err = self.on_signal(s.(unix.Signal))
 			if err != nil {
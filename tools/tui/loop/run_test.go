@@ -0,0 +1,105 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestStyledUnderlinesDetection(t *testing.T) {
+	smulx := hex.EncodeToString([]byte("Smulx"))
+	var supported_calls int
+	var rc_responses, query_responses, dcs_codes []string
+	// a loop in the state it is in after Run() has sent the query
+	detecting_loop := func() *Loop {
+		lp := new_loop().DetectStyledUnderlines()
+		lp.style_ctx.NoStyledUnderlines = true
+		lp.style_cache["curly-underline"] = nil
+		lp.OnStyledUnderlinesSupported = func() error { supported_calls++; return nil }
+		lp.OnRCResponse = func(data []byte) error { rc_responses = append(rc_responses, string(data)); return nil }
+		lp.OnEscapeCode = func(et EscapeCodeType, data []byte) error {
+			if et == DCS {
+				dcs_codes = append(dcs_codes, string(data))
+			}
+			return nil
+		}
+		return lp
+	}
+	dcs := func(lp *Loop, raw string) {
+		t.Helper()
+		if err := lp.handle_dcs([]byte(raw)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(lp *Loop, expected_supported bool, expected_dcs ...string) {
+		t.Helper()
+		if lp.style_ctx.NoStyledUnderlines == expected_supported {
+			t.Fatalf("NoStyledUnderlines is %v", lp.style_ctx.NoStyledUnderlines)
+		}
+		if expected_supported != (len(lp.style_cache) == 0) {
+			t.Fatalf("style_cache was not cleared correctly: %v", lp.style_cache)
+		}
+		if expected_supported != (supported_calls == 1) || supported_calls > 1 {
+			t.Fatalf("OnStyledUnderlinesSupported called %d times", supported_calls)
+		}
+		if diff := cmp.Diff(expected_dcs, dcs_codes); diff != "" {
+			t.Fatalf("Unexpected DCS escape codes:\n%s", diff)
+		}
+		supported_calls, dcs_codes = 0, nil
+	}
+
+	lp := detecting_loop()
+	dcs(lp, "1+r"+smulx+"="+hex.EncodeToString([]byte(`\E[4:%p1%dm`)))
+	check(lp, true)
+	// a repeated response does not call OnStyledUnderlinesSupported again
+	dcs(lp, "1+r"+smulx)
+	if supported_calls != 0 {
+		t.Fatalf("OnStyledUnderlinesSupported called for repeated response")
+	}
+
+	lp = detecting_loop()
+	dcs(lp, "0+r"+smulx)
+	check(lp, false)
+
+	// responses for other capabilities are not consumed
+	lp = detecting_loop()
+	other := "1+r" + hex.EncodeToString([]byte("Smul")) + "=" + hex.EncodeToString([]byte(`\E[4m`))
+	dcs(lp, other)
+	check(lp, false, other)
+	lp.OnQueryResponse = func(key, val string, valid bool) error {
+		query_responses = append(query_responses, fmt.Sprintf("%s=%s %v", key, val, valid))
+		return nil
+	}
+	dcs(lp, "1+r"+hex.EncodeToString([]byte("kitty-query-name"))+"="+hex.EncodeToString([]byte("xterm-kitty")))
+	if diff := cmp.Diff([]string{"name=xterm-kitty true"}, query_responses); diff != "" {
+		t.Fatalf("Unexpected query responses:\n%s", diff)
+	}
+	check(lp, false)
+
+	// remote control responses are unaffected
+	dcs(lp, `@kitty-cmd{"ok": true}`)
+	if diff := cmp.Diff([]string{`{"ok": true}`}, rc_responses); diff != "" {
+		t.Fatalf("Unexpected remote control responses:\n%s", diff)
+	}
+	check(lp, false)
+
+	// without detection, the response is not consumed
+	lp = detecting_loop()
+	lp.detect_styled_underlines = false
+	dcs(lp, "1+r"+smulx)
+	check(lp, false, "1+r"+smulx)
+
+	// an explicit opt-out wins regardless of order
+	if new_loop().NoStyledUnderlines().DetectStyledUnderlines().detect_styled_underlines {
+		t.Fatalf("DetectStyledUnderlines overrode NoStyledUnderlines")
+	}
+	if lp = new_loop().DetectStyledUnderlines().NoStyledUnderlines(); lp.detect_styled_underlines || !lp.style_ctx.NoStyledUnderlines {
+		t.Fatalf("NoStyledUnderlines did not override DetectStyledUnderlines")
+	}
+}
@@ -0,0 +1,255 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"golang.org/x/sys/unix"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type SubprocessOutputCallback func(data []byte) error
+
+// A child process whose output is delivered to the loop. All callbacks are
+// called on the loop's main thread.
+type Subprocess struct {
+	// The command to run. If Stdout or Stderr are set on it, the corresponding
+	// callback must be nil, otherwise StartSubprocess fails. Its Stdin, Stdout
+	// and Stderr, if set, must be *os.File as the loop waits for the child
+	// itself, so Cmd.Wait() must not be called and Cmd.ProcessState is not set.
+	Cmd *exec.Cmd
+	// Deliver output in chunks as it is read rather than line-by-line
+	RawOutput bool
+	// Called with every line (without the trailing newline) or chunk of output
+	OnStdout, OnStderr SubprocessOutputCallback
+	// Called once the child has exited and all its output has been delivered,
+	// that is, EOF has been reached on both stdout and stderr. Note that if the
+	// child leaves behind a process that keeps these open, this is not called
+	// until that process exits as well. If the exit status of the child could
+	// not be determined, err is non-nil and status must be ignored.
+	OnExit func(status unix.WaitStatus, err error) error
+
+	id           IdType
+	readers      []*os.File
+	open_streams int
+	exited       bool
+	status       unix.WaitStatus
+	status_err   error
+}
+
+type subprocess_event struct {
+	id        IdType
+	is_stderr bool
+	data      []byte
+	eof       bool
+}
+
+func (self *Loop) read_subprocess_output(id IdType, is_stderr, raw bool, r *os.File) {
+	events, done := self.subprocess_channel, self.subprocess_done_channel
+	defer r.Close()
+	send := func(ev subprocess_event) bool {
+		select {
+		case events <- ev:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	var err error
+	if raw {
+		buf := make([]byte, utils.DEFAULT_IO_BUFFER_SIZE)
+		for err == nil {
+			var n int
+			if n, err = r.Read(buf); n > 0 {
+				if !send(subprocess_event{id: id, is_stderr: is_stderr, data: bytes.Clone(buf[:n])}) {
+					return
+				}
+			}
+		}
+	} else {
+		br := bufio.NewReader(r)
+		for err == nil {
+			var line []byte
+			if line, err = br.ReadBytes('\n'); len(line) > 0 {
+				line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+				if !send(subprocess_event{id: id, is_stderr: is_stderr, data: line}) {
+					return
+				}
+			}
+		}
+	}
+	send(subprocess_event{id: id, is_stderr: is_stderr, eof: true})
+}
+
+// Start the specified child process, delivering its output and exit
+// notification to the loop. Can only be called while the loop is running, for
+// example, from OnInitialize. Any children still running when the loop exits
+// are killed.
+func (self *Loop) StartSubprocess(p *Subprocess) (IdType, error) {
+	if self.subprocesses == nil {
+		return 0, fmt.Errorf("Cannot start subprocesses before starting the run loop, start them in OnInitialize instead")
+	}
+	var pipes []*os.File
+	close_pipes := func() {
+		for _, f := range pipes {
+			f.Close()
+		}
+		// do not leave closed pipes in Cmd
+		if p.OnStdout != nil {
+			p.Cmd.Stdout = nil
+		}
+		if p.OnStderr != nil {
+			p.Cmd.Stderr = nil
+		}
+	}
+	type reader struct {
+		r         *os.File
+		is_stderr bool
+	}
+	readers := make([]reader, 0, 2)
+	add_pipe := func(is_stderr bool) (*os.File, error) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		pipes = append(pipes, r, w)
+		readers = append(readers, reader{r, is_stderr})
+		return w, nil
+	}
+	if p.OnStdout != nil && p.Cmd.Stdout != nil {
+		return 0, fmt.Errorf("Cannot specify both OnStdout and Cmd.Stdout for a subprocess")
+	}
+	if p.OnStderr != nil && p.Cmd.Stderr != nil {
+		return 0, fmt.Errorf("Cannot specify both OnStderr and Cmd.Stderr for a subprocess")
+	}
+	// os/exec would otherwise copy data in goroutines that are only cleaned
+	// up by Cmd.Wait()
+	for i, x := range []any{p.Cmd.Stdin, p.Cmd.Stdout, p.Cmd.Stderr} {
+		if _, is_file := x.(*os.File); x != nil && !is_file {
+			return 0, fmt.Errorf("Cmd.%s for a subprocess must be an *os.File, not: %T", []string{"Stdin", "Stdout", "Stderr"}[i], x)
+		}
+	}
+	var err error
+	if p.OnStdout != nil {
+		if p.Cmd.Stdout, err = add_pipe(false); err != nil {
+			close_pipes()
+			return 0, err
+		}
+	}
+	if p.OnStderr != nil {
+		if p.Cmd.Stderr, err = add_pipe(true); err != nil {
+			close_pipes()
+			return 0, err
+		}
+	}
+	if err = p.Cmd.Start(); err != nil {
+		close_pipes()
+		return 0, err
+	}
+	// close the write ends in this process so that readers get EOF when the child exits
+	for i := 1; i < len(pipes); i += 2 {
+		pipes[i].Close()
+	}
+	self.subprocess_id_counter++
+	p.id, p.open_streams, p.exited = self.subprocess_id_counter, len(readers), false
+	p.readers = make([]*os.File, len(readers))
+	self.subprocesses[p.id] = p
+	for i, x := range readers {
+		p.readers[i] = x.r
+		go self.read_subprocess_output(p.id, x.is_stderr, p.RawOutput, x.r)
+	}
+	return p.id, nil
+}
+
+// Send the specified signal to the child process with the specified id
+func (self *Loop) SignalSubprocess(id IdType, sig unix.Signal) error {
+	p := self.subprocesses[id]
+	if p == nil || p.exited {
+		return fmt.Errorf("No running subprocess with id: %d", id)
+	}
+	return p.Cmd.Process.Signal(sig)
+}
+
+func (self *Loop) maybe_finish_subprocess(p *Subprocess) error {
+	if !p.exited || p.open_streams > 0 {
+		return nil
+	}
+	delete(self.subprocesses, p.id)
+	if p.OnExit != nil {
+		return p.OnExit(p.status, p.status_err)
+	}
+	return nil
+}
+
+func (self *Loop) reap_subprocesses() error {
+	for _, p := range self.subprocesses {
+		if p.exited {
+			continue
+		}
+		var status unix.WaitStatus
+		pid, err := unix.Wait4(p.Cmd.Process.Pid, &status, unix.WNOHANG, nil)
+		if err != nil && errors.Is(err, unix.EINTR) {
+			pid, err = unix.Wait4(p.Cmd.Process.Pid, &status, unix.WNOHANG, nil)
+		}
+		if pid == p.Cmd.Process.Pid || errors.Is(err, unix.ECHILD) {
+			p.exited, p.status = true, status
+			if err != nil {
+				// already reaped elsewhere, so the exit status is lost
+				p.status_err = fmt.Errorf("Could not get the exit status of subprocess %d: %w", p.Cmd.Process.Pid, err)
+			}
+			_ = p.Cmd.Process.Release()
+			if err = self.maybe_finish_subprocess(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (self *Loop) on_SIGCHLD() error {
+	return self.reap_subprocesses()
+}
+
+func (self *Loop) dispatch_subprocess_event(ev subprocess_event) error {
+	p := self.subprocesses[ev.id]
+	if p == nil {
+		return nil
+	}
+	if ev.eof {
+		p.open_streams--
+		return self.maybe_finish_subprocess(p)
+	}
+	if ev.is_stderr {
+		return p.OnStderr(ev.data)
+	}
+	return p.OnStdout(ev.data)
+}
+
+func (self *Loop) kill_subprocesses() {
+	for _, p := range self.subprocesses {
+		if !p.exited {
+			_ = p.Cmd.Process.Kill()
+			var status unix.WaitStatus
+			for {
+				if _, err := unix.Wait4(p.Cmd.Process.Pid, &status, 0, nil); !errors.Is(err, unix.EINTR) {
+					break
+				}
+			}
+			_ = p.Cmd.Process.Release()
+		}
+		// unblock the readers in case some other process still has the pipes open
+		for _, r := range p.readers {
+			r.Close()
+		}
+	}
+	self.subprocesses = nil
+}
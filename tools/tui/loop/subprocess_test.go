@@ -0,0 +1,203 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+func subprocess_test_loop() *Loop {
+	self := new_loop()
+	self.subprocesses = make(map[IdType]*Subprocess)
+	self.subprocess_channel = make(chan subprocess_event, 256)
+	self.subprocess_done_channel = make(chan byte)
+	return self
+}
+
+func TestSubprocessOutput(t *testing.T) {
+	self := subprocess_test_loop()
+	defer close(self.subprocess_done_channel)
+
+	read := func(raw bool, data ...string) (ans []string) {
+		t.Helper()
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		go self.read_subprocess_output(7, true, raw, r)
+		for _, x := range data {
+			if _, err = w.Write([]byte(x)); err != nil {
+				t.Fatal(err)
+			}
+			if raw {
+				// ensure each write is read as a separate chunk
+				ev := <-self.subprocess_channel
+				ans = append(ans, string(ev.data))
+			}
+		}
+		w.Close()
+		for ev := range self.subprocess_channel {
+			if ev.id != 7 || !ev.is_stderr {
+				t.Fatalf("Event has incorrect metadata: %#v", ev)
+			}
+			if ev.eof {
+				if ev.data != nil {
+					t.Fatalf("EOF event has data: %#v", ev)
+				}
+				break
+			}
+			ans = append(ans, string(ev.data))
+		}
+		return
+	}
+	test := func(raw bool, data []string, expected ...string) {
+		t.Helper()
+		if diff := cmp.Diff(expected, read(raw, data...)); diff != "" {
+			t.Fatalf("Unexpected output for raw=%v and input %#v:\n%s", raw, data, diff)
+		}
+	}
+	test(false, []string{"one\ntw", "o\r\n\nthree"}, "one", "two", "", "three")
+	test(false, []string{"a\r\nb\n"}, "a", "b")
+	test(false, nil)
+	test(true, []string{"one\ntw", "o\r\n"}, "one\ntw", "o\r\n")
+	test(true, nil)
+}
+
+// Run the parts of the loop that handle subprocesses until done() returns true
+func run_subprocess_loop(t *testing.T, self *Loop, done func() bool) {
+	t.Helper()
+	signal_channel := make(chan os.Signal, 256)
+	signal.Notify(signal_channel, unix.SIGCHLD)
+	defer signal.Reset(unix.SIGCHLD)
+	// a child that exited before Notify was called would be missed
+	if err := self.on_signal(unix.SIGCHLD); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(10 * time.Second)
+	for !done() {
+		var err error
+		select {
+		case ev := <-self.subprocess_channel:
+			err = self.dispatch_subprocess_event(ev)
+		case s := <-signal_channel:
+			err = self.on_signal(s.(unix.Signal))
+		case <-timeout:
+			t.Fatalf("Timed out waiting for subprocess")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSubprocessExit(t *testing.T) {
+	self := subprocess_test_loop()
+	defer close(self.subprocess_done_channel)
+
+	var stdout, stderr []string
+	exit_status := -1
+	streams_open_at_exit := false
+	p := &Subprocess{
+		Cmd:      exec.Command("sh", "-c", "echo out; echo err >&2; sleep 0.1; echo out2; exit 3"),
+		OnStdout: func(data []byte) error { stdout = append(stdout, string(data)); return nil },
+		OnStderr: func(data []byte) error { stderr = append(stderr, string(data)); return nil },
+	}
+	p.OnExit = func(status unix.WaitStatus, err error) error {
+		if err != nil {
+			return err
+		}
+		streams_open_at_exit = p.open_streams > 0
+		exit_status = status.ExitStatus()
+		return nil
+	}
+	id, err := self.StartSubprocess(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if self.subprocesses[id] != p {
+		t.Fatalf("Subprocess not registered with id: %d", id)
+	}
+	run_subprocess_loop(t, self, func() bool { return exit_status > -1 })
+	if streams_open_at_exit {
+		t.Fatalf("OnExit called before all output was delivered")
+	}
+	if exit_status != 3 {
+		t.Fatalf("Unexpected exit status: %d", exit_status)
+	}
+	if diff := cmp.Diff([]string{"out", "out2"}, stdout); diff != "" {
+		t.Fatalf("Unexpected stdout:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"err"}, stderr); diff != "" {
+		t.Fatalf("Unexpected stderr:\n%s", diff)
+	}
+	if len(self.subprocesses) != 0 {
+		t.Fatalf("Finished subprocess was not removed")
+	}
+}
+
+func TestSubprocessInvalidCmd(t *testing.T) {
+	self := subprocess_test_loop()
+	defer close(self.subprocess_done_channel)
+	on_output := func([]byte) error { return nil }
+
+	p := &Subprocess{Cmd: exec.Command("true"), OnStdout: on_output}
+	p.Cmd.Stdout = os.Stdout
+	if _, err := self.StartSubprocess(p); err == nil {
+		t.Fatalf("No error when both OnStdout and Cmd.Stdout are set")
+	}
+	p = &Subprocess{Cmd: exec.Command("true")}
+	p.Cmd.Stdin = strings.NewReader("xxx")
+	if _, err := self.StartSubprocess(p); err == nil || !strings.Contains(err.Error(), "Stdin") {
+		t.Fatalf("No error when Cmd.Stdin is not a file: %v", err)
+	}
+
+	// a failed start does not leave the pipes in Cmd
+	p = &Subprocess{Cmd: &exec.Cmd{Path: filepath.Join(t.TempDir(), "does-not-exist")}, OnStdout: on_output, OnStderr: on_output}
+	if _, err := self.StartSubprocess(p); err == nil || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Unexpected error for a nonexistent executable: %v", err)
+	}
+	if p.Cmd.Stdout != nil || p.Cmd.Stderr != nil {
+		t.Fatalf("Cmd.Stdout and Cmd.Stderr not reset after a failed start")
+	}
+	if len(self.subprocesses) != 0 {
+		t.Fatalf("Subprocess registered despite failing to start")
+	}
+}
+
+func TestSubprocessOutputHeldOpen(t *testing.T) {
+	self := subprocess_test_loop()
+	var stdout []string
+	exited := false
+	p := &Subprocess{
+		// the grandchild keeps stdout open after the child exits
+		Cmd:      exec.Command("sh", "-c", "sleep 2 & echo started"),
+		OnStdout: func(data []byte) error { stdout = append(stdout, string(data)); return nil },
+		OnExit:   func(unix.WaitStatus, error) error { exited = true; return nil },
+	}
+	if _, err := self.StartSubprocess(p); err != nil {
+		t.Fatal(err)
+	}
+	run_subprocess_loop(t, self, func() bool { return p.exited && len(stdout) > 0 })
+	if exited || p.open_streams != 1 {
+		t.Fatalf("OnExit called while the output is still open")
+	}
+	// what the loop does on shutdown
+	close(self.subprocess_done_channel)
+	self.kill_subprocesses()
+	if _, err := p.readers[0].Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("Reader not closed on shutdown: %v", err)
+	}
+}
@@ -36,7 +36,9 @@ func (self *SelectionBoundary) LessThan(other *SelectionBoundary) bool {
 		return false
 	}
 	if self.x == other.x {
-		return !self.in_first_half_of_cell && other.in_first_half_of_cell
+		// a boundary in the first half of a cell is before the cell, so it
+		// sorts before a boundary in the second half of the same cell
+		return self.in_first_half_of_cell && !other.in_first_half_of_cell
 	}
 	return self.x < other.x
 }
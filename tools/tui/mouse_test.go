@@ -0,0 +1,53 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"testing"
+
+	"kitty/tools/tui/loop"
+)
+
+var _ = fmt.Print
+
+func TestMouseSelectionLineBounds(t *testing.T) {
+	const cw = 10
+	line := &screen_line_pos{y: 0, max_x: 9}
+	ev := func(x int, in_first_half bool) *loop.MouseEvent {
+		ans := loop.MouseEvent{}
+		ans.Cell.X, ans.Pixel.X = x, x*cw+cw-1
+		if in_first_half {
+			ans.Pixel.X = x * cw
+		}
+		return &ans
+	}
+	test := func(sx int, s_first_half bool, ex int, e_first_half bool, expected_start, expected_end int) {
+		t.Helper()
+		ms := MouseSelection{}
+		ms.StartNewSelection(ev(sx, s_first_half), line, 0, 0, cw, cw)
+		ms.Update(ev(ex, e_first_half), line)
+		if s, e := ms.LineBounds(line); s != expected_start || e != expected_end {
+			t.Fatalf("Selection from (%d, %v) to (%d, %v): (%d, %d) != (%d, %d)",
+				sx, s_first_half, ex, e_first_half, expected_start, expected_end, s, e)
+		}
+	}
+	// within a single cell, in both drag directions
+	test(3, true, 3, false, 3, 3)
+	test(3, false, 3, true, 3, 3)
+	test(3, true, 3, true, -1, -1)
+	test(3, false, 3, false, -1, -1)
+	// across cells, in both drag directions
+	test(3, true, 5, false, 3, 5)
+	test(5, false, 3, true, 3, 5)
+	test(3, true, 4, true, 3, 3)
+	test(4, true, 3, true, 3, 3)
+	test(3, false, 4, true, -1, -1)
+	// other lines are not selected
+	ms := MouseSelection{}
+	ms.StartNewSelection(ev(3, true), line, 0, 1, cw, cw)
+	ms.Update(ev(5, false), line)
+	if s, e := ms.LineBounds(&screen_line_pos{y: 1, max_x: 9}); s != -1 || e != -1 {
+		t.Fatalf("Line outside the selection has bounds: (%d, %d)", s, e)
+	}
+}
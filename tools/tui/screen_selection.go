@@ -0,0 +1,244 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+type screen_line_pos struct {
+	y, max_x int
+}
+
+func (self *screen_line_pos) MinX() int { return 0 }
+func (self *screen_line_pos) MaxX() int { return self.max_x }
+func (self *screen_line_pos) Equal(other LinePos) bool {
+	if o, ok := other.(*screen_line_pos); ok {
+		return self.y == o.y
+	}
+	return false
+}
+
+func (self *screen_line_pos) LessThan(other LinePos) bool {
+	if o, ok := other.(*screen_line_pos); ok {
+		return self.y < o.y
+	}
+	return false
+}
+
+// ScreenSelection is an opt-in selection layer for applications that enable
+// mouse tracking and thus lose the terminal's native text selection. Pass it
+// every mouse event and it tracks drag selections over the lines currently
+// displayed on the screen, and copies the selected text via OSC 52 when the
+// left mouse button is released. Call Render() after drawing the screen to
+// highlight the selection.
+type ScreenSelection struct {
+	// Return the text, which may contain escape codes, displayed on the
+	// specified screen line (0-based). Required.
+	LineAt func(y int) string
+	// The style used to highlight the selection, reverse video when empty
+	Style string
+	// Copy selected text to the primary selection rather than the clipboard
+	UsePrimarySelection bool
+	// Called when the selection changes, typically this should redraw the screen
+	OnChange func() error
+
+	lp        *loop.Loop
+	selection MouseSelection
+	// used instead of the loop when set, for testing
+	screen_size func() (loop.ScreenSize, error)
+	copy_text   func(text string, to_primary_selection bool)
+}
+
+const default_selection_style = "reverse"
+
+func NewScreenSelection(lp *loop.Loop, line_at func(y int) string) *ScreenSelection {
+	return &ScreenSelection{lp: lp, LineAt: line_at, Style: default_selection_style}
+}
+
+func (self *ScreenSelection) line_pos(y int) *screen_line_pos {
+	w := wcswidth.Stringwidth(self.LineAt(y))
+	return &screen_line_pos{y: y, max_x: max(0, w-1)}
+}
+
+func (self *ScreenSelection) num_lines() (int, loop.ScreenSize) {
+	var sz loop.ScreenSize
+	if self.screen_size != nil {
+		sz, _ = self.screen_size()
+	} else {
+		sz, _ = self.lp.ScreenSize()
+	}
+	return int(sz.HeightCells), sz
+}
+
+func (self *ScreenSelection) changed() error {
+	if self.OnChange != nil {
+		return self.OnChange()
+	}
+	return nil
+}
+
+// Whether there is a non-empty selection
+func (self *ScreenSelection) HasSelection() bool { return !self.selection.IsEmpty() }
+
+// Whether a drag selection is currently in progress
+func (self *ScreenSelection) IsActive() bool { return self.selection.IsActive() }
+
+// Remove the current selection, if any
+func (self *ScreenSelection) Clear() error {
+	if self.selection.IsEmpty() && !self.selection.IsActive() {
+		return nil
+	}
+	self.selection.Clear()
+	return self.changed()
+}
+
+// Handle a mouse event, returns true if the event was consumed by the
+// selection layer. Presses are never consumed, so that clicks still reach the
+// application, only drags that extend a selection are. If the left button is
+// found to no longer be held during a drag, because the release happened
+// outside the window, the selection is finished as if it had been released.
+func (self *ScreenSelection) HandleMouseEvent(ev *loop.MouseEvent) (handled bool, err error) {
+	switch ev.Event_type {
+	case loop.MOUSE_PRESS:
+		if ev.Buttons&loop.LEFT_MOUSE_BUTTON == 0 {
+			return false, nil
+		}
+		n, sz := self.num_lines()
+		if ev.Cell.Y < 0 || ev.Cell.Y >= n {
+			return false, nil
+		}
+		had_selection := !self.selection.IsEmpty()
+		self.selection.StartNewSelection(ev, self.line_pos(ev.Cell.Y), 0, n-1, int(sz.CellWidth), int(sz.CellHeight))
+		if had_selection {
+			err = self.changed()
+		}
+		return false, err
+	case loop.MOUSE_MOVE:
+		if !self.selection.IsActive() {
+			return false, nil
+		}
+		if ev.Buttons&loop.LEFT_MOUSE_BUTTON == 0 {
+			self.selection.Finish()
+			self.CopySelection()
+			return false, nil
+		}
+		self.update(ev)
+		if self.selection.IsEmpty() {
+			return false, nil
+		}
+		return true, self.changed()
+	case loop.MOUSE_RELEASE:
+		if !self.selection.IsActive() || ev.Buttons&loop.LEFT_MOUSE_BUTTON == 0 {
+			return false, nil
+		}
+		self.update(ev)
+		self.selection.Finish()
+		if !self.CopySelection() {
+			return false, nil
+		}
+		return true, self.changed()
+	}
+	return false, nil
+}
+
+func (self *ScreenSelection) update(ev *loop.MouseEvent) {
+	n, _ := self.num_lines()
+	y := max(0, min(ev.Cell.Y, n-1))
+	self.selection.Update(ev, self.line_pos(y))
+}
+
+func (self *ScreenSelection) ordered_lines() (start, end int) {
+	start, end = self.selection.StartLine().(*screen_line_pos).y, self.selection.EndLine().(*screen_line_pos).y
+	if end < start {
+		start, end = end, start
+	}
+	return
+}
+
+// The text of all cells in line that overlap the columns from start_x to
+// end_x (inclusive), so that partially selected wide characters are included.
+// Also returns the columns spanned by those cells, -1 if there are none.
+func cells_in_columns(line string, start_x, end_x int) (text string, first_x, last_x int) {
+	b := strings.Builder{}
+	x := 0
+	first_x, last_x = -1, -1
+	for it := wcswidth.NewCellIterator(line); it.Forward() && x <= end_x; {
+		cell := it.Current()
+		w := wcswidth.Stringwidth(cell)
+		if cell_end := x + max(w, 1) - 1; cell_end >= start_x {
+			b.WriteString(cell)
+			if first_x < 0 {
+				first_x = x
+			}
+			last_x = cell_end
+		}
+		x += w
+	}
+	return b.String(), first_x, last_x
+}
+
+// The text in the current selection with all formatting removed
+func (self *ScreenSelection) Text() string {
+	if self.selection.IsEmpty() {
+		return ""
+	}
+	start, end := self.ordered_lines()
+	lines := make([]string, 0, end-start+1)
+	for y := start; y <= end; y++ {
+		line := wcswidth.StripEscapeCodes(self.LineAt(y))
+		s, e := self.selection.LineBounds(self.line_pos(y))
+		if s < 0 {
+			lines = append(lines, "")
+			continue
+		}
+		text, _, _ := cells_in_columns(line, s, e)
+		lines = append(lines, strings.TrimRight(text, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Copy the selected text to the clipboard (or primary selection) via OSC 52.
+// Returns false if there is no selected text.
+func (self *ScreenSelection) CopySelection() bool {
+	text := self.Text()
+	if text == "" {
+		return false
+	}
+	if self.copy_text != nil {
+		self.copy_text(text, self.UsePrimarySelection)
+	} else if self.UsePrimarySelection {
+		self.lp.CopyTextToPrimarySelection(text)
+	} else {
+		self.lp.CopyTextToClipboard(text)
+	}
+	return true
+}
+
+// Highlight the current selection on screen. Must be called after the screen
+// contents have been drawn as it restyles the already drawn cells.
+func (self *ScreenSelection) Render() {
+	if self.selection.IsEmpty() {
+		return
+	}
+	style := self.Style
+	if style == "" {
+		style = default_selection_style
+	}
+	start, end := self.ordered_lines()
+	for y := start; y <= end; y++ {
+		if s, e := self.selection.LineBounds(self.line_pos(y)); s > -1 {
+			// highlight whole cells, matching what is copied
+			line := wcswidth.StripEscapeCodes(self.LineAt(y))
+			if _, s, e = cells_in_columns(line, s, e); s > -1 {
+				self.lp.StyleRegion(style, s, y, e, y)
+			}
+		}
+	}
+}
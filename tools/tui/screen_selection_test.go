@@ -0,0 +1,166 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"kitty/tools/tui/loop"
+)
+
+var _ = fmt.Print
+
+func TestScreenSelectionText(t *testing.T) {
+	lines := []string{
+		"\x1b[31mred\x1b[39m \x1b[1mbold\x1b[221m",
+		"a世界b",
+		"",
+		"last line",
+	}
+	ss := ScreenSelection{LineAt: func(y int) string { return lines[y] }}
+	const cw = 10
+	ev := func(x, y int, in_first_half bool) *loop.MouseEvent {
+		ans := loop.MouseEvent{}
+		ans.Cell.X, ans.Cell.Y = x, y
+		ans.Pixel.X, ans.Pixel.Y = x*cw+cw-1, y*cw
+		if in_first_half {
+			ans.Pixel.X = x * cw
+		}
+		return &ans
+	}
+	// a selection that includes both the start and the end cells
+	test := func(sx, sy, ex, ey int, expected string) {
+		t.Helper()
+		reversed := ey < sy || (ey == sy && ex < sx)
+		ss.selection.StartNewSelection(ev(sx, sy, !reversed), ss.line_pos(sy), 0, len(lines)-1, cw, cw)
+		ss.selection.Update(ev(ex, ey, reversed), ss.line_pos(ey))
+		if actual := ss.Text(); actual != expected {
+			t.Fatalf("Selection from (%d, %d) to (%d, %d) failed: %#v != %#v", sx, sy, ex, ey, expected, actual)
+		}
+	}
+	test(0, 0, 2, 0, "red")
+	test(4, 0, 7, 0, "bold")
+	test(2, 0, 5, 0, "d bo")
+	test(0, 1, 0, 1, "a")
+	test(1, 1, 2, 1, "世")
+	test(2, 1, 3, 1, "世界")
+	test(3, 1, 5, 1, "界b")
+	test(4, 0, 2, 1, "bold\na世")
+	test(5, 3, 4, 0, "bold\na世界b\n\nlast l")
+	test(8, 3, 0, 2, "\nlast line")
+
+	ss.selection.Clear()
+	if ss.Text() != "" {
+		t.Fatalf("Cleared selection has text: %#v", ss.Text())
+	}
+}
+
+func TestScreenSelectionCellBounds(t *testing.T) {
+	test := func(line string, start_x, end_x int, expected string, first_x, last_x int) {
+		t.Helper()
+		text, f, l := cells_in_columns(line, start_x, end_x)
+		if text != expected || f != first_x || l != last_x {
+			t.Fatalf("Cells of %#v in columns %d..%d: (%#v, %d, %d) != (%#v, %d, %d)", line, start_x, end_x, expected, first_x, last_x, text, f, l)
+		}
+	}
+	test("a世界b", 2, 3, "世界", 1, 4)
+	test("a世界b", 0, 1, "a世", 0, 2)
+	test("a世界b", 5, 5, "b", 5, 5)
+	test("abc", 1, 1, "b", 1, 1)
+	test("", 0, 0, "", -1, -1)
+}
+
+func TestScreenSelectionMouseEvents(t *testing.T) {
+	lines := []string{"red bold", "a世界b", "", "last line"}
+	const cw, ch = 10, 20
+	var copied []string
+	num_changes := 0
+	ss := ScreenSelection{
+		LineAt:   func(y int) string { return lines[y] },
+		OnChange: func() error { num_changes++; return nil },
+		screen_size: func() (loop.ScreenSize, error) {
+			return loop.ScreenSize{WidthCells: 20, HeightCells: uint(len(lines)), CellWidth: cw, CellHeight: ch, WidthPx: 20 * cw, HeightPx: uint(len(lines)) * ch}, nil
+		},
+		copy_text: func(text string, to_primary_selection bool) {
+			if to_primary_selection {
+				text = "primary:" + text
+			}
+			copied = append(copied, text)
+		},
+	}
+	ev := func(event_type loop.MouseEventType, buttons loop.MouseButtonFlag, x, y int, in_first_half bool) *loop.MouseEvent {
+		ans := loop.MouseEvent{Event_type: event_type, Buttons: buttons}
+		ans.Cell.X, ans.Cell.Y = x, y
+		ans.Pixel.X, ans.Pixel.Y = x*cw+cw-1, y*ch
+		if in_first_half {
+			ans.Pixel.X = x * cw
+		}
+		return &ans
+	}
+	const left, right = loop.LEFT_MOUSE_BUTTON, loop.RIGHT_MOUSE_BUTTON
+	send := func(e *loop.MouseEvent, expected bool) {
+		t.Helper()
+		handled, err := ss.HandleMouseEvent(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if handled != expected {
+			t.Fatalf("The event %s was handled: %v expected: %v", e, handled, expected)
+		}
+	}
+	check_copied := func(expected ...string) {
+		t.Helper()
+		if diff := cmp.Diff(expected, copied); diff != "" {
+			t.Fatalf("Unexpected copied text:\n%s", diff)
+		}
+		copied = nil
+	}
+
+	// presses are never consumed
+	send(ev(loop.MOUSE_PRESS, left, 0, 0, true), false)
+	if !ss.IsActive() || ss.HasSelection() {
+		t.Fatalf("Press did not start an empty selection")
+	}
+	// moves are only consumed once the selection is non-empty
+	send(ev(loop.MOUSE_MOVE, left, 0, 0, true), false)
+	send(ev(loop.MOUSE_MOVE, left, 2, 0, false), true)
+	if num_changes != 1 {
+		t.Fatalf("OnChange not called for selection change")
+	}
+	send(ev(loop.MOUSE_RELEASE, left, 2, 0, false), true)
+	check_copied("red")
+	if ss.IsActive() || ss.Text() != "red" {
+		t.Fatalf("Release did not finish the selection")
+	}
+
+	// a click without a drag clears the selection and copies nothing
+	num_changes = 0
+	send(ev(loop.MOUSE_PRESS, left, 4, 0, true), false)
+	if num_changes != 1 || ss.HasSelection() {
+		t.Fatalf("Press did not clear the previous selection")
+	}
+	send(ev(loop.MOUSE_RELEASE, left, 4, 0, true), false)
+	check_copied()
+
+	// other buttons are ignored
+	send(ev(loop.MOUSE_PRESS, right, 1, 1, true), false)
+	if ss.IsActive() {
+		t.Fatalf("Right button press started a selection")
+	}
+
+	// a lost release finishes the selection on the next move
+	ss.UsePrimarySelection = true
+	send(ev(loop.MOUSE_PRESS, left, 1, 1, true), false)
+	send(ev(loop.MOUSE_MOVE, left, 3, 3, false), true)
+	send(ev(loop.MOUSE_MOVE, loop.NO_MOUSE_BUTTON, 8, 3, false), false)
+	check_copied("primary:世界b\n\nlast")
+	if ss.IsActive() || ss.Text() != "世界b\n\nlast" {
+		t.Fatalf("Move without the left button held did not finish the selection: %#v", ss.Text())
+	}
+	send(ev(loop.MOUSE_MOVE, loop.NO_MOUSE_BUTTON, 0, 0, false), false)
+	send(ev(loop.MOUSE_RELEASE, left, 0, 0, false), false)
+	check_copied()
+}
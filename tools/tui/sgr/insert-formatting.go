@@ -100,9 +100,9 @@ type ColorVal struct {
 }
 
 type SGR struct {
-	Italic, Reverse, Bold, Dim, Strikethrough BoolVal
-	Underline_style                           UnderlineStyleVal
-	Foreground, Background, Underline_color   ColorVal
+	Italic, Reverse, Bold, Dim, Strikethrough, Overline BoolVal
+	Underline_style                                     UnderlineStyleVal
+	Foreground, Background, Underline_color             ColorVal
 }
 
 func (self *BoolVal) AsCSI(set, reset string) string {
@@ -145,6 +145,7 @@ func (self *SGR) AsCSI() string {
 	w(self.Italic.AsCSI("3", "23"))
 	w(self.Reverse.AsCSI("7", "27"))
 	w(self.Strikethrough.AsCSI("9", "29"))
+	w(self.Overline.AsCSI("53", "55"))
 	w(self.Underline_style.AsCSI())
 	w(self.Foreground.AsCSI(30))
 	w(self.Background.AsCSI(40))
@@ -158,7 +159,7 @@ func (self *SGR) AsCSI() string {
 }
 
 func (self *SGR) IsEmpty() bool {
-	return !(self.Foreground.Is_set || self.Background.Is_set || self.Underline_color.Is_set || self.Underline_style.Is_set || self.Italic.Is_set || self.Bold.Is_set || self.Reverse.Is_set || self.Dim.Is_set || self.Strikethrough.Is_set)
+	return !(self.Foreground.Is_set || self.Background.Is_set || self.Underline_color.Is_set || self.Underline_style.Is_set || self.Italic.Is_set || self.Bold.Is_set || self.Reverse.Is_set || self.Dim.Is_set || self.Strikethrough.Is_set || self.Overline.Is_set)
 }
 
 func (self *SGR) ApplyMask(other SGR) {
@@ -177,6 +178,9 @@ func (self *SGR) ApplyMask(other SGR) {
 	if other.Strikethrough.Is_set {
 		self.Strikethrough.Is_set = false
 	}
+	if other.Overline.Is_set {
+		self.Overline.Is_set = false
+	}
 	if other.Underline_style.Is_set {
 		self.Underline_style.Is_set = false
 	}
@@ -207,6 +211,9 @@ func (self *SGR) ApplySGR(other SGR) {
 	if other.Strikethrough.Is_set {
 		self.Strikethrough = other.Strikethrough
 	}
+	if other.Overline.Is_set {
+		self.Overline = other.Overline
+	}
 	if other.Underline_style.Is_set {
 		self.Underline_style = other.Underline_style
 	}
@@ -269,6 +276,10 @@ func SGRFromCSI(csi string) (ans SGR) {
 			ans.Strikethrough.Is_set, ans.Strikethrough.Val = true, true
 		case 29:
 			ans.Strikethrough.Is_set, ans.Strikethrough.Val = true, false
+		case 53:
+			ans.Overline.Is_set, ans.Overline.Val = true, true
+		case 55:
+			ans.Overline.Is_set, ans.Overline.Val = true, false
 		case 24:
 			ans.Underline_style.Is_set, ans.Underline_style.Val = true, No_underline
 		case 4:
@@ -388,6 +399,10 @@ func (self *Span) SetStrikethrough(val bool) *Span {
 	self.opening_sgr.Strikethrough.Set(val)
 	return self
 }
+func (self *Span) SetOverline(val bool) *Span {
+	self.opening_sgr.Overline.Set(val)
+	return self
+}
 func (self *Span) SetUnderlineStyle(val UnderlineStyle) *Span {
 	self.opening_sgr.Underline_style.Is_set = true
 	self.opening_sgr.Underline_style.Val = val
@@ -430,6 +445,10 @@ func (self *Span) SetClosingStrikethrough(val bool) *Span {
 	self.closing_sgr.Strikethrough.Set(val)
 	return self
 }
+func (self *Span) SetClosingOverline(val bool) *Span {
+	self.closing_sgr.Overline.Set(val)
+	return self
+}
 func (self *Span) SetClosingUnderlineStyle(val UnderlineStyle) *Span {
 	self.closing_sgr.Underline_style.Is_set = true
 	self.opening_sgr.Underline_style.Val = val
@@ -33,4 +33,9 @@ func TestInsertFormatting(t *testing.T) {
 		"A\x1b[37mB\x1b[1mC\x1b[221mDE\x1b[39m\x1b[221m",
 		NewSpan(1, 11).SetForeground(7).SetClosingForeground(nil),
 	)
+	test(
+		"abcd",
+		"a\x1b[53mbc\x1b[55md",
+		NewSpan(1, 2).SetOverline(true).SetClosingOverline(false),
+	)
 }
@@ -21,6 +21,7 @@ type mark_set = *utils.Set[uint16]
 var unicode_name_data string
 var _ = fmt.Print
 var names map[rune]string
+var aliases map[rune]string
 var marks []rune
 var word_map map[string][]uint16
 
@@ -56,6 +57,7 @@ func parse_record(record []byte, mark uint16) {
 	names[codepoint] = name
 	add_words(mark, record[:namelen])
 	if len(record) > int(namelen) {
+		aliases[codepoint] = utils.UnsafeBytesToString(record[namelen:])
 		add_words(mark, record[namelen:])
 	}
 }
@@ -68,7 +70,12 @@ func parse_data() {
 	raw = raw[4:]
 	num_of_words := binary.LittleEndian.Uint32(raw)
 	raw = raw[4:]
+	records_len := binary.LittleEndian.Uint32(raw)
+	raw = raw[4:]
+	parse_trigram_index(raw[records_len:])
+	raw = raw[:records_len]
 	names = make(map[rune]string, num_of_lines)
+	aliases = make(map[rune]string)
 	word_map = make(map[string][]uint16, num_of_words)
 	marks = make([]rune, num_of_lines)
 	var mark uint16
@@ -85,11 +92,23 @@ func Initialize() {
 	parse_once.Do(parse_data)
 }
 
-func NameForCodePoint(cp rune) string {
+// The name of the specified codepoint or the empty string if it has no name
+func NameFor(cp rune) string {
 	Initialize()
 	return names[cp]
 }
 
+// Deprecated: use NameFor instead
+func NameForCodePoint(cp rune) string {
+	return NameFor(cp)
+}
+
+// Alternate names for the specified codepoint, such as HTML entity names
+func AliasesFor(cp rune) []string {
+	Initialize()
+	return strings.Fields(aliases[cp])
+}
+
 func find_matching_codepoints(prefix string) (ans mark_set) {
 	for q, marks := range word_map {
 		if strings.HasPrefix(q, prefix) {
@@ -147,4 +166,7 @@ func Develop() {
 	start = time.Now()
 	num = CodePointsForQuery("arr right")
 	fmt.Println("Querying arr right took:", time.Since(start), "and found:", len(num))
+	start = time.Now()
+	num = Search("arr")
+	fmt.Println("Searching arr took:", time.Since(start), "and found:", len(num))
 }
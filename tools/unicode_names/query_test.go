@@ -32,4 +32,19 @@ func TestUnicodeInputQueries(t *testing.T) {
 	if slices.Index(CodePointsForQuery("bee"), 0x1f41d) < 0 {
 		t.Fatalf("The query bee did not match the codepoint: 0x1f41d")
 	}
+	if q := Search("snowman"); len(q) == 0 || q[0] != 0x2603 {
+		t.Fatalf("The search snowman did not return the codepoint 0x2603 first: %#v", q)
+	}
+	if len(CodePointsForQuery("owman")) != 0 || slices.Index(Search("owman"), 0x2603) < 0 {
+		t.Fatalf("The search owman did not match the codepoint: 0x2603")
+	}
+	if q := Search("bee"); len(q) == 0 || q[0] != 0x1f41d {
+		t.Fatalf("The search bee did not return the codepoint 0x1f41d first: %#v", q)
+	}
+	if len(Search("kfjhgkjdsfhgkjds")) != 0 {
+		t.Fatalf("The search kfjhgkjdsfhgkjds returned results")
+	}
+	if NameFor(0x1f41d) != "honeybee" || slices.Index(AliasesFor(0x1f41d), "bee") < 0 {
+		t.Fatalf("Incorrect name or aliases for 0x1f41d: %#v %#v", NameFor(0x1f41d), AliasesFor(0x1f41d))
+	}
 }
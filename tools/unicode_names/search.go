@@ -0,0 +1,180 @@
+// License: GPLv3 Copyright: 2024, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package unicode_names
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// The trigram index generated by gen/go_code.py: the sorted list of all words
+// in names and aliases and a table, sorted by trigram, of every three byte
+// sequence with the sorted indices of the words that contain it. It is used in
+// place in the decompressed data, so no work is needed to build it.
+var all_words []string
+var trigram_table []byte
+var trigram_postings []byte
+
+const trigram_entry_size = 3 + 4 + 2 // trigram, offset into postings, count
+
+func parse_trigram_index(raw []byte) {
+	num_of_words := binary.LittleEndian.Uint32(raw)
+	raw = raw[4:]
+	all_words = make([]string, num_of_words)
+	for i := range all_words {
+		n := int(raw[0])
+		all_words[i] = utils.UnsafeBytesToString(raw[1 : n+1])
+		raw = raw[n+1:]
+	}
+	num_of_trigrams := binary.LittleEndian.Uint32(raw)
+	raw = raw[4:]
+	trigram_table = raw[:num_of_trigrams*trigram_entry_size]
+	trigram_postings = raw[len(trigram_table):]
+}
+
+// The sorted indices of the words in all_words that contain the trigram t
+func words_containing(t string) []uint16 {
+	n := len(trigram_table) / trigram_entry_size
+	i, found := sort.Find(n, func(i int) int {
+		return strings.Compare(t, utils.UnsafeBytesToString(trigram_table[i*trigram_entry_size:i*trigram_entry_size+3]))
+	})
+	if !found {
+		return nil
+	}
+	entry := trigram_table[i*trigram_entry_size+3:]
+	offset, count := binary.LittleEndian.Uint32(entry), binary.LittleEndian.Uint16(entry[4:])
+	postings := trigram_postings[2*offset:]
+	ans := make([]uint16, count)
+	for j := range ans {
+		ans[j] = binary.LittleEndian.Uint16(postings[2*j:])
+	}
+	return ans
+}
+
+func intersect_sorted(a, b []uint16) (ans []uint16) {
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			ans = append(ans, a[i])
+			i++
+			j++
+		}
+	}
+	return
+}
+
+// Codepoints that have a word containing term, which must be at least three bytes long
+func find_codepoints_containing(term string) (ans mark_set) {
+	Initialize()
+	var candidates []uint16
+	for j := 0; j+3 <= len(term); j++ {
+		p := words_containing(term[j : j+3])
+		if j == 0 {
+			candidates = p
+		} else {
+			candidates = intersect_sorted(candidates, p)
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+	for _, idx := range candidates {
+		w := all_words[idx]
+		if m := word_map[w]; len(m) > 0 && (len(term) == 3 || strings.Contains(w, term)) {
+			if ans == nil {
+				ans = utils.NewSet[uint16](len(candidates) * 2)
+			}
+			ans.AddItems(m...)
+		}
+	}
+	return
+}
+
+// How well the name or aliases of cp match the query, lower is better
+func match_quality(cp rune, query string, terms []string) int {
+	name := names[cp]
+	if name == query {
+		return 0
+	}
+	cp_aliases := strings.Fields(aliases[cp])
+	if slices.Contains(cp_aliases, query) {
+		return 0
+	}
+	words := append(strings.Fields(name), cp_aliases...)
+	for _, term := range terms {
+		if !slices.ContainsFunc(words, func(w string) bool { return strings.HasPrefix(w, term) }) {
+			return 2
+		}
+	}
+	return 1
+}
+
+// Search for codepoints whose name or aliases match every word in query. Query
+// words shorter than three letters must match the start of a word, longer
+// ones can match anywhere inside a word. Results are sorted with exact name or
+// alias matches first, followed by those in which all query words are
+// prefixes of words in the name or aliases, then everything else. Within each
+// group, private use codepoints such as icons sort after standard ones and
+// shorter names sort first.
+func Search(query string) []rune {
+	Initialize()
+	terms := strings.Fields(strings.ToLower(query))
+	ans := []rune{}
+	if len(terms) == 0 {
+		return ans
+	}
+	var matches mark_set
+	for _, term := range terms {
+		var m mark_set
+		if len(term) < 3 {
+			m = find_matching_codepoints(term)
+		} else {
+			m = find_codepoints_containing(term)
+		}
+		if m == nil {
+			return ans
+		}
+		if matches == nil {
+			matches = m
+		} else {
+			matches = matches.Intersect(m)
+		}
+	}
+	for m := range matches.Iterable() {
+		ans = append(ans, marks[m])
+	}
+	query = strings.Join(terms, " ")
+	quality, private_use := make(map[rune]int, len(ans)), make(map[rune]int, len(ans))
+	for _, cp := range ans {
+		quality[cp] = match_quality(cp, query, terms)
+		if unicode.Is(unicode.Co, cp) {
+			private_use[cp] = 1
+		}
+	}
+	slices.SortFunc(ans, func(a, b rune) int {
+		if c := cmp.Compare(quality[a], quality[b]); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(private_use[a], private_use[b]); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(names[a]), len(names[b])); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	return ans
+}
@@ -9,11 +9,15 @@ import (
 
 type Context struct {
 	AllowEscapeCodes bool
+	// Render styled underlines (curly, dotted, etc.) as straight underlines
+	// and ignore underline colors, for terminals that do not support them. Loop
+	// based programs can set this automatically with loop.DetectStyledUnderlines
+	NoStyledUnderlines bool
 }
 
 func (self *Context) SprintFunc(spec string) func(args ...any) string {
-	p := prefix_for_spec(spec)
-	s := suffix_for_spec(spec)
+	p := prefix_for_spec(spec, self.NoStyledUnderlines)
+	s := suffix_for_spec(spec, self.NoStyledUnderlines)
 
 	return func(args ...any) string {
 		body := fmt.Sprint(args...)
@@ -30,8 +34,8 @@ func (self *Context) SprintFunc(spec string) func(args ...any) string {
 }
 
 func (self *Context) UrlFunc(spec string) func(string, string) string {
-	p := prefix_for_spec(spec)
-	s := suffix_for_spec(spec)
+	p := prefix_for_spec(spec, self.NoStyledUnderlines)
+	s := suffix_for_spec(spec, self.NoStyledUnderlines)
 
 	return func(url, text string) string {
 		if !self.AllowEscapeCodes {
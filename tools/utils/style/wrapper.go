@@ -273,9 +273,9 @@ func (self underline_value) as_sgr(prefix, suffix []string) ([]string, []string)
 // }}}
 
 type sgr_code struct {
-	bold, italic, reverse, dim, strikethrough bool_value
-	fg, bg, uc                                color_value
-	underline                                 underline_value
+	bold, italic, reverse, dim, strikethrough, overline bool_value
+	fg, bg, uc                                          color_value
+	underline                                           underline_value
 
 	_prefix, _suffix string
 }
@@ -316,6 +316,7 @@ func (self *sgr_code) update() {
 	p, s = self.italic.as_sgr("3", "23", p, s)
 	p, s = self.reverse.as_sgr("7", "27", p, s)
 	p, s = self.strikethrough.as_sgr("9", "29", p, s)
+	p, s = self.overline.as_sgr("53", "55", p, s)
 	p, s = self.underline.as_sgr(p, s)
 	p, s = self.fg.as_sgr(30, p, s)
 	p, s = self.bg.as_sgr(40, p, s)
@@ -332,7 +333,16 @@ func (self *sgr_code) update() {
 	}
 }
 
-func parse_spec(spec string) []escape_code {
+// Replace styled underlines with straight underlines and drop underline colors
+// for terminals that do not support them
+func (self *sgr_code) use_plain_underline() {
+	if self.underline.is_set && self.underline.style != no_underline {
+		self.underline.style = straight_underline
+	}
+	self.uc.is_set = false
+}
+
+func parse_spec(spec string, plain_underline bool) []escape_code {
 	ans := make([]escape_code, 0, 1)
 	sgr := sgr_code{}
 	sparts, _ := shlex.Split(spec)
@@ -358,10 +368,15 @@ func parse_spec(spec string) []escape_code {
 			sgr.underline.from_string(val)
 		case "strikethrough", "s":
 			sgr.strikethrough.from_string(val)
+		case "overline", "o":
+			sgr.overline.from_string(val)
 		case "ucol", "underline_color", "uc":
 			sgr.uc.from_string(val)
 		}
 	}
+	if plain_underline {
+		sgr.use_plain_underline()
+	}
 	sgr.update()
 	if !sgr.is_empty() {
 		ans = append(ans, &sgr)
@@ -369,31 +384,37 @@ func parse_spec(spec string) []escape_code {
 	return ans
 }
 
-var parsed_spec_cache = make(map[string][]escape_code)
+type spec_cache_key struct {
+	spec            string
+	plain_underline bool
+}
+
+var parsed_spec_cache = make(map[spec_cache_key][]escape_code)
 var parsed_spec_cache_mutex = sync.Mutex{}
 
-func cached_parse_spec(spec string) []escape_code {
+func cached_parse_spec(spec string, plain_underline bool) []escape_code {
 	parsed_spec_cache_mutex.Lock()
 	defer parsed_spec_cache_mutex.Unlock()
-	if val, ok := parsed_spec_cache[spec]; ok {
+	key := spec_cache_key{spec, plain_underline}
+	if val, ok := parsed_spec_cache[key]; ok {
 		return val
 	}
-	ans := parse_spec(spec)
-	parsed_spec_cache[spec] = ans
+	ans := parse_spec(spec, plain_underline)
+	parsed_spec_cache[key] = ans
 	return ans
 }
 
-func prefix_for_spec(spec string) string {
+func prefix_for_spec(spec string, plain_underline bool) string {
 	sb := strings.Builder{}
-	for _, ec := range cached_parse_spec(spec) {
+	for _, ec := range cached_parse_spec(spec, plain_underline) {
 		sb.WriteString(ec.prefix())
 	}
 	return sb.String()
 }
 
-func suffix_for_spec(spec string) string {
+func suffix_for_spec(spec string, plain_underline bool) string {
 	sb := strings.Builder{}
-	for _, ec := range cached_parse_spec(spec) {
+	for _, ec := range cached_parse_spec(spec, plain_underline) {
 		sb.WriteString(ec.suffix())
 	}
 	return sb.String()
@@ -43,6 +43,20 @@ func TestANSIStyleSprint(t *testing.T) {
 	test("bg=123", "\x1b[48:5:123m", "\x1b[49m")
 	test("uc=123", "\x1b[58:5:123m", "\x1b[59m")
 	test("uc=1", "\x1b[58:5:1m", "\x1b[59m")
+	test("o s", "\x1b[9;53m", "\x1b[29;55m")
+	test("overline=false", "\x1b[55m", "\x1b[53m")
+
+	plain := Context{AllowEscapeCodes: true, NoStyledUnderlines: true}
+	for spec, expected := range map[string]string{
+		"u=curly uc=red": "\x1b[4:1m  \x1b[4:0m",
+		"u=dotted bold":  "\x1b[1;4:1m  \x1b[221;4:0m",
+		"u=none":         "\x1b[4:0m  \x1b[4:0m",
+		"uc=red":         "  ",
+	} {
+		if actual := plain.SprintFunc(spec)("  "); actual != expected {
+			t.Fatalf("Formatting with plain underlines and spec: %s failed expected != actual: %#v != %#v", spec, expected, actual)
+		}
+	}
 
 	actual := ctx.UrlFunc("u=curly uc=cyan")("http://moo.com", "___")
 	expected := "\x1b[4:3;58:5:6m\x1b]8;;http://moo.com\x1b\\___\x1b]8;;\x1b\\\x1b[4:0;59m"